@@ -0,0 +1,158 @@
+package secure
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/volatile/core"
+)
+
+// maxCSPReportBodySize bounds how much of a violation report body handleCSPReport will read. ReportURI is
+// an unauthenticated, publicly reachable endpoint, so without a cap a client could make it buffer an
+// arbitrarily large body in memory before parsing even starts.
+const maxCSPReportBodySize = 1 << 20 // 1 MiB
+
+// CSPOptions represents Content Security Policy options.
+type CSPOptions struct {
+	Policy        string                          // Policy contains the Content Security Policy directives for responses. See http://www.w3.org/TR/CSP/ and https://developer.mozilla.org/en-US/docs/Web/Security/CSP/Using_Content_Security_Policy.
+	ReportOnly    bool                            // ReportOnly makes the policy observational: it's sent as Content-Security-Policy-Report-Only instead of being enforced.
+	ReportURI     string                          // ReportURI is the path violation reports are sent to. Set ReportHandler to also receive them on that path.
+	ReportTo      string                          // ReportTo is the reporting group name added to the policy for browsers supporting the Reporting API. See https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Report-To.
+	ReportHandler func(*core.Context, *CSPReport) // ReportHandler, when set along with ReportURI, is called for every violation report posted to ReportURI.
+}
+
+// CSPReport represents a Content Security Policy violation report, normalized from either the legacy
+// application/csp-report body or the newer application/reports+json Reporting API body.
+type CSPReport struct {
+	DocumentURI        string // DocumentURI is the URI of the document in which the violation occurred.
+	Referrer           string // Referrer is the referrer of the document in which the violation occurred.
+	ViolatedDirective  string // ViolatedDirective is the directive whose enforcement caused the violation.
+	EffectiveDirective string // EffectiveDirective is the directive that was violated.
+	OriginalPolicy     string // OriginalPolicy is the original policy the user agent applied.
+	Disposition        string // Disposition is "enforce" or "report".
+	BlockedURI         string // BlockedURI is the URI of the resource that was blocked.
+	StatusCode         int    // StatusCode is the HTTP status code of the document in which the violation occurred.
+	ScriptSample       string // ScriptSample is a sample of the script that caused the violation, when available.
+}
+
+// cspReportBody is the JSON layout of a legacy application/csp-report body, as defined by CSP Level 2.
+type cspReportBody struct {
+	Report struct {
+		DocumentURI        string `json:"document-uri"`
+		Referrer           string `json:"referrer"`
+		ViolatedDirective  string `json:"violated-directive"`
+		EffectiveDirective string `json:"effective-directive"`
+		OriginalPolicy     string `json:"original-policy"`
+		Disposition        string `json:"disposition"`
+		BlockedURI         string `json:"blocked-uri"`
+		StatusCode         int    `json:"status-code"`
+		ScriptSample       string `json:"script-sample"`
+	} `json:"csp-report"`
+}
+
+// reportToBody is the JSON layout of a single entry of an application/reports+json body, as defined by the
+// Reporting API. Only the "csp-violation" report type is decoded; other types are ignored.
+type reportToBody struct {
+	Type string `json:"type"`
+	Body struct {
+		DocumentURL        string `json:"documentURL"`
+		Referrer           string `json:"referrer"`
+		ViolatedDirective  string `json:"violatedDirective"`
+		EffectiveDirective string `json:"effectiveDirective"`
+		OriginalPolicy     string `json:"originalPolicy"`
+		Disposition        string `json:"disposition"`
+		BlockedURL         string `json:"blockedURL"`
+		StatusCode         int    `json:"statusCode"`
+		Sample             string `json:"sample"`
+	} `json:"body"`
+}
+
+// cspHeader builds the value of the Content-Security-Policy (or Content-Security-Policy-Report-Only) header.
+func cspHeader(o *CSPOptions) string {
+	v := o.Policy
+
+	if o.ReportURI != "" {
+		if v != "" {
+			v += "; "
+		}
+		v += "report-uri " + o.ReportURI
+	}
+
+	if o.ReportTo != "" {
+		if v != "" {
+			v += "; "
+		}
+		v += "report-to " + o.ReportTo
+	}
+
+	return v
+}
+
+// handleCSPReport reads and parses a violation report posted to a CSP's ReportURI and dispatches it to
+// the CSP's ReportHandler.
+func handleCSPReport(c *core.Context, o *CSPOptions) {
+	defer c.Request.Body.Close()
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.ResponseWriter, c.Request.Body, maxCSPReportBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(c.ResponseWriter, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(c.ResponseWriter, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var reports []*CSPReport
+
+	switch c.Request.Header.Get("Content-Type") {
+	case "application/reports+json":
+		var entries []reportToBody
+		if err := json.Unmarshal(body, &entries); err != nil {
+			http.Error(c.ResponseWriter, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, e := range entries {
+			if e.Type != "csp-violation" {
+				continue
+			}
+			reports = append(reports, &CSPReport{
+				DocumentURI:        e.Body.DocumentURL,
+				Referrer:           e.Body.Referrer,
+				ViolatedDirective:  e.Body.ViolatedDirective,
+				EffectiveDirective: e.Body.EffectiveDirective,
+				OriginalPolicy:     e.Body.OriginalPolicy,
+				Disposition:        e.Body.Disposition,
+				BlockedURI:         e.Body.BlockedURL,
+				StatusCode:         e.Body.StatusCode,
+				ScriptSample:       e.Body.Sample,
+			})
+		}
+	default: // application/csp-report, and anything else we're willing to try as such.
+		var r cspReportBody
+		if err := json.Unmarshal(body, &r); err != nil {
+			http.Error(c.ResponseWriter, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reports = append(reports, &CSPReport{
+			DocumentURI:        r.Report.DocumentURI,
+			Referrer:           r.Report.Referrer,
+			ViolatedDirective:  r.Report.ViolatedDirective,
+			EffectiveDirective: r.Report.EffectiveDirective,
+			OriginalPolicy:     r.Report.OriginalPolicy,
+			Disposition:        r.Report.Disposition,
+			BlockedURI:         r.Report.BlockedURI,
+			StatusCode:         r.Report.StatusCode,
+			ScriptSample:       r.Report.ScriptSample,
+		})
+	}
+
+	for _, report := range reports {
+		o.ReportHandler(c, report)
+	}
+
+	c.ResponseWriter.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,91 @@
+package secure
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/volatile/core"
+)
+
+func TestCSPHeader(t *testing.T) {
+	if got, want := cspHeader(&CSPOptions{Policy: "default-src 'self'"}), "default-src 'self'"; got != want {
+		t.Errorf("cspHeader() = %q, want %q", got, want)
+	}
+
+	o := &CSPOptions{Policy: "default-src 'self'", ReportURI: "/csp-report", ReportTo: "csp-endpoint"}
+	if got, want := cspHeader(o), "default-src 'self'; report-uri /csp-report; report-to csp-endpoint"; got != want {
+		t.Errorf("cspHeader() = %q, want %q", got, want)
+	}
+
+	if got, want := cspHeader(&CSPOptions{ReportURI: "/csp-report"}), "report-uri /csp-report"; got != want {
+		t.Errorf("cspHeader() with no Policy = %q, want %q", got, want)
+	}
+}
+
+func TestHandleCSPReportLegacy(t *testing.T) {
+	body := `{"csp-report":{"document-uri":"https://example.com/","violated-directive":"script-src","blocked-uri":"https://evil.example/"}}`
+	req := httptest.NewRequest("POST", "/csp-report", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	var got *CSPReport
+	handleCSPReport(&core.Context{Request: req, ResponseWriter: rec}, &CSPOptions{
+		ReportHandler: func(_ *core.Context, r *CSPReport) { got = r },
+	})
+
+	if got == nil {
+		t.Fatal("ReportHandler wasn't called")
+	}
+	if got.DocumentURI != "https://example.com/" || got.ViolatedDirective != "script-src" || got.BlockedURI != "https://evil.example/" {
+		t.Errorf("handleCSPReport() parsed %+v", got)
+	}
+	if rec.Code != 204 {
+		t.Errorf("handleCSPReport() status = %d, want 204", rec.Code)
+	}
+}
+
+func TestHandleCSPReportReportingAPI(t *testing.T) {
+	body := `[{"type":"csp-violation","body":{"documentURL":"https://example.com/","violatedDirective":"script-src","blockedURL":"https://evil.example/"}},{"type":"deprecation","body":{}}]`
+	req := httptest.NewRequest("POST", "/csp-report", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/reports+json")
+	rec := httptest.NewRecorder()
+
+	var reports []*CSPReport
+	handleCSPReport(&core.Context{Request: req, ResponseWriter: rec}, &CSPOptions{
+		ReportHandler: func(_ *core.Context, r *CSPReport) { reports = append(reports, r) },
+	})
+
+	if len(reports) != 1 {
+		t.Fatalf("handleCSPReport() dispatched %d reports, want 1 (the deprecation entry must be ignored)", len(reports))
+	}
+	if reports[0].DocumentURI != "https://example.com/" || reports[0].ViolatedDirective != "script-src" {
+		t.Errorf("handleCSPReport() parsed %+v", reports[0])
+	}
+}
+
+func TestHandleCSPReportOversizedBody(t *testing.T) {
+	body := strings.Repeat("a", maxCSPReportBodySize+1)
+	req := httptest.NewRequest("POST", "/csp-report", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	handleCSPReport(&core.Context{Request: req, ResponseWriter: rec}, &CSPOptions{
+		ReportHandler: func(*core.Context, *CSPReport) { t.Error("ReportHandler shouldn't be called for an oversized body") },
+	})
+
+	if rec.Code != 413 {
+		t.Errorf("handleCSPReport() status = %d, want 413", rec.Code)
+	}
+}
+
+func TestHandleCSPReportInvalidBody(t *testing.T) {
+	req := httptest.NewRequest("POST", "/csp-report", strings.NewReader("not json"))
+	rec := httptest.NewRecorder()
+
+	handleCSPReport(&core.Context{Request: req, ResponseWriter: rec}, &CSPOptions{
+		ReportHandler: func(*core.Context, *CSPReport) { t.Error("ReportHandler shouldn't be called for an invalid body") },
+	})
+
+	if rec.Code != 400 {
+		t.Errorf("handleCSPReport() status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,26 @@
+package secure
+
+import "fmt"
+
+// ExpectCTOptions represents Expect-CT options.
+// See https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Expect-CT.
+type ExpectCTOptions struct {
+	MaxAge    int    // MaxAge indicates the number of seconds the browser should remember that this site must supply a valid certificate transparency record.
+	Enforce   bool   // Enforce indicates whether the browser must refuse future connections that don't comply, rather than only reporting the failure.
+	ReportURI string // ReportURI is the URL compliance failures are reported to.
+}
+
+// expectCTHeader builds the value of the Expect-CT header.
+func expectCTHeader(o *ExpectCTOptions) string {
+	v := fmt.Sprintf("max-age=%d", o.MaxAge)
+
+	if o.Enforce {
+		v += ", enforce"
+	}
+
+	if o.ReportURI != "" {
+		v += fmt.Sprintf(", report-uri=%q", o.ReportURI)
+	}
+
+	return v
+}
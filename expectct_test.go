@@ -0,0 +1,14 @@
+package secure
+
+import "testing"
+
+func TestExpectCTHeader(t *testing.T) {
+	if got, want := expectCTHeader(&ExpectCTOptions{MaxAge: 86400}), "max-age=86400"; got != want {
+		t.Errorf("expectCTHeader() = %q, want %q", got, want)
+	}
+
+	o := &ExpectCTOptions{MaxAge: 86400, Enforce: true, ReportURI: "https://example.com/expect-ct-report"}
+	if got, want := expectCTHeader(o), `max-age=86400, enforce, report-uri="https://example.com/expect-ct-report"`; got != want {
+		t.Errorf("expectCTHeader() = %q, want %q", got, want)
+	}
+}
@@ -2,192 +2,184 @@ package secure
 
 import (
 	"errors"
-	"fmt"
+	"log"
 	"net/http"
-	"time"
 
 	"github.com/volatile/core"
 )
 
-const (
-	// HPKPDefaultMaxAge provides a default HPKP Max-Age value of 30 days.
-	HPKPDefaultMaxAge = 30 * 24 * time.Hour
-	// HSTSDefaultMaxAge provides a default HSTS Max-Age value of 30 days.
-	HSTSDefaultMaxAge = 30 * 24 * time.Hour
-	// HSTSPreloadMinAge is the lowest max age usable with HSTS preload. See https://hstspreload.appspot.com.
-	HSTSPreloadMinAge = 10886400
-)
-
 // Options represents security options.
 type Options struct {
-	AllowedHosts []string     // AllowedHosts indicates which fully qualified domain names are allowed to point to this server. If none are set, all are allowed.
-	CSP          string       // CSP contains Content Security Policy for responses. See http://www.w3.org/TR/CSP/ and https://developer.mozilla.org/en-US/docs/Web/Security/CSP/Using_Content_Security_Policy.
-	FrameAllowed bool         // FrameAllowed indicates whether the browsers can display the response in a frame, regardless of the site attempting to do so.
-	HPKP         *HPKPOptions // HPKP contains the HTTP Public Key Pinning options.
-	HSTS         *HSTSOptions // HPKP contains the HTTP Strict Transport Security options.
-	SSLForced    bool         // SSLForced indicates whether an insecure request must be redirected to the secure protocol.
+	AllowedHosts              []string            // AllowedHosts indicates which fully qualified domain names are allowed to point to this server. If none are set, all are allowed.
+	CSP                       *CSPOptions         // CSP contains the Content Security Policy options for responses. See http://www.w3.org/TR/CSP/ and https://developer.mozilla.org/en-US/docs/Web/Security/CSP/Using_Content_Security_Policy.
+	CrossOriginEmbedderPolicy string              // CrossOriginEmbedderPolicy contains the Cross-Origin-Embedder-Policy token ("unsafe-none", "require-corp" or "credentialless"). If empty, the header is omitted.
+	CrossOriginOpenerPolicy   string              // CrossOriginOpenerPolicy contains the Cross-Origin-Opener-Policy token ("unsafe-none", "same-origin-allow-popups" or "same-origin"). If empty, the header is omitted.
+	CrossOriginResourcePolicy string              // CrossOriginResourcePolicy contains the Cross-Origin-Resource-Policy token ("same-site", "same-origin" or "cross-origin"). If empty, the header is omitted.
+	ExpectCT                  *ExpectCTOptions    // ExpectCT contains the Expect-CT options.
+	FrameAllowed              bool                // FrameAllowed indicates whether the browsers can display the response in a frame, regardless of the site attempting to do so.
+	HPKP                      *HPKPOptions        // HPKP contains the HTTP Public Key Pinning options. Deprecated: no current browser honors Public-Key-Pins; use ExpectCT and PinVerifier instead.
+	HSTS                      *HSTSOptions        // HSTS contains the HTTP Strict Transport Security options.
+	PermissionsPolicy         map[string][]string // PermissionsPolicy maps directive names to their allowlists, serialized to the Permissions-Policy header. If nil, the header is omitted.
+	PreloadCheckPath          string              // PreloadCheckPath, when set, mounts a GET handler at that path reporting this configuration's readiness for the HSTS preload list as JSON. Its live redirect and certificate checks only run against hosts listed in AllowedHosts, since the request's Host header can't be trusted to pick a host to dial.
+	ReferrerPolicy            string              // ReferrerPolicy contains the Referrer-Policy token (e.g. "strict-origin-when-cross-origin"). If empty, the header is omitted.
+	SSLForced                 bool                // SSLForced indicates whether an insecure request must be redirected to the secure protocol.
 }
 
-// HPKPOptions represents HTTP Public Key Pinning options.
-// See RFC 7469 and https://developer.mozilla.org/en-US/docs/Web/Security/Public_Key_Pinning.
-type HPKPOptions struct {
-	Keys              []string      // Keys contains the Base64 encoded Subject Public Key Information (SPKI) fingerprints. This field is required.
-	MaxAge            time.Duration // MaxAge indicates how long the browser should remember that this site is only to be accessed using one of the pinned keys. This field is required.
-	IncludeSubdomains bool          // IncludeSubdomains indicates whether HPKP applies to all of the site's subdomains as well.
-	ReportURI         string        // ReportURI is the URL at which validation failures are reported to.
+// Use adds the handler to the default handlers stack.
+func Use(options *Options) {
+	validateOptions(options)
+
+	core.Use(func(c *core.Context) {
+		if apply(c, options) {
+			return
+		}
+		c.Next()
+	})
 }
 
-// HSTSOptions represents HTTP Strict Transport Security options.
-// See RFC 6797 and https://developer.mozilla.org/en-US/docs/Web/Security/HTTP_strict_transport_security.
-type HSTSOptions struct {
-	MaxAge            time.Duration // MaxAge indicates how long the browser should remember that this site is only to be accessed using HTTPS. This field is required.
-	IncludeSubdomains bool          // IncludeSubdomains indicates whether HSTS applies to all of the site's subdomains as well.
-	Preload           bool          // Preload indicates whether the browsers must use a secure connection. It's not a standard. See https://hstspreload.appspot.com.
+// validateOptions panics when options hold a configuration the handler can't serve, e.g. an incomplete
+// HPKP or HSTS setup.
+func validateOptions(options *Options) {
+	if options == nil {
+		return
+	}
+
+	if options.HPKP != nil {
+		if _, err := hpkpHeader(options); err != nil {
+			panic(err)
+		}
+		if !options.HPKP.Deprecated {
+			log.Println("secure: HPKP is deprecated and ignored by every current browser; switch to ExpectCT and PinVerifier, or set HPKP.Deprecated to true to silence this warning")
+		}
+	}
+	if options.HSTS != nil {
+		if _, err := hstsHeader(options); err != nil {
+			panic(err)
+		}
+	}
+	if err := validateHeaderOptions(options); err != nil {
+		panic(err)
+	}
 }
 
-// Use adds the handler to the default handlers stack.
-func Use(options *Options) {
-	// Panic when options are invalid.
+// apply sets the response headers options calls for and reports whether the request was already fully
+// handled (a CSP report, a redirect, a disallowed host), in which case the caller must not call c.Next().
+func apply(c *core.Context, options *Options) bool {
 	if options != nil {
-		if options.HPKP != nil {
-			if _, err := hpkpHeader(options); err != nil {
-				panic(err)
-			}
+		// Dispatch CSP violation reports before anything else, since the reporting endpoint isn't a page request.
+		if options.CSP != nil && options.CSP.ReportHandler != nil && options.CSP.ReportURI != "" &&
+			c.Request.Method == http.MethodPost && c.Request.URL.Path == options.CSP.ReportURI {
+			handleCSPReport(c, options.CSP)
+			return true
 		}
-		if options.HSTS != nil {
-			if _, err := hstsHeader(options); err != nil {
-				panic(err)
-			}
+
+		// Likewise for the HSTS preload readiness report.
+		if options.PreloadCheckPath != "" && c.Request.Method == http.MethodGet && c.Request.URL.Path == options.PreloadCheckPath {
+			writePreloadReport(c, options)
+			return true
 		}
-	}
 
-	core.Use(func(c *core.Context) {
-		if options != nil {
-			if core.Production {
-				// Check if host is allowed.
-				if len(options.AllowedHosts) > 0 {
-					for _, host := range options.AllowedHosts {
-						if host == c.Request.URL.Host {
-							goto SSLOptions
-						}
+		if core.Production {
+			// Check if host is allowed.
+			if len(options.AllowedHosts) > 0 {
+				for _, host := range options.AllowedHosts {
+					if host == c.Request.URL.Host {
+						goto SSLOptions
 					}
-					http.NotFound(c.ResponseWriter, c.Request)
-					return
 				}
+				http.NotFound(c.ResponseWriter, c.Request)
+				return true
+			}
 
-			SSLOptions:
-				isSSL := (c.Request.URL.Scheme == "https" || c.Request.TLS != nil || c.Request.Header.Get("X-Forwarded-Proto") == "https")
+		SSLOptions:
+			isSSL := (c.Request.URL.Scheme == "https" || c.Request.TLS != nil || c.Request.Header.Get("X-Forwarded-Proto") == "https")
 
-				// If wanted, redirect permanently to the secure protocol.
-				if !isSSL && options.SSLForced {
-					url := c.Request.URL
-					url.Scheme = "https"
-					http.Redirect(c.ResponseWriter, c.Request, url.String(), http.StatusMovedPermanently)
-					return
-				}
+			// If wanted, redirect permanently to the secure protocol.
+			if !isSSL && options.SSLForced {
+				url := c.Request.URL
+				url.Scheme = "https"
+				http.Redirect(c.ResponseWriter, c.Request, url.String(), http.StatusMovedPermanently)
+				return true
+			}
 
-				// Set HPKP header, but only if connected by SSL and the HPKP options are valid.
-				if isSSL && options.HPKP != nil {
-					if v, err := hpkpHeader(options); err != nil {
-						panic(err)
-					} else {
-						c.ResponseWriter.Header().Set("Public-Key-Pins", v)
-					}
+			// Set HPKP header, but only if connected by SSL and the HPKP options are valid.
+			if isSSL && options.HPKP != nil {
+				if v, err := hpkpHeader(options); err != nil {
+					panic(err)
+				} else {
+					c.ResponseWriter.Header().Set("Public-Key-Pins", v)
 				}
+			}
 
-				// HSTS header, but only if HSTS options are valid.
-				if options.HSTS != nil {
-					if v, err := hstsHeader(options); err != nil {
-						panic(err)
-					} else {
-						c.ResponseWriter.Header().Set("Strict-Transport-Security", v)
-					}
+			// HSTS header, but only if HSTS options are valid.
+			if options.HSTS != nil {
+				if v, err := hstsHeader(options); err != nil {
+					panic(err)
+				} else {
+					c.ResponseWriter.Header().Set("Strict-Transport-Security", v)
 				}
 			}
 
-			// Set Content Security Policy headers.
-			if options.CSP != "" {
-				c.ResponseWriter.Header().Set("Content-Security-Policy", options.CSP)
-				c.ResponseWriter.Header().Set("X-Content-Security-Policy", options.CSP)
-				c.ResponseWriter.Header().Set("X-WebKit-CSP", options.CSP)
+			// Set Expect-CT header, but only if connected by SSL.
+			if isSSL && options.ExpectCT != nil {
+				c.ResponseWriter.Header().Set("Expect-CT", expectCTHeader(options.ExpectCT))
 			}
 		}
 
-		// If not explicitly allowed, displaying content inside a frame of a different origin is forbidden.
-		if options == nil || !options.FrameAllowed {
-			c.ResponseWriter.Header().Set("X-Frame-Options", "SAMEORIGIN")
+		// Set Referrer-Policy, Permissions-Policy and Cross-Origin-*-Policy headers.
+		if options.ReferrerPolicy != "" {
+			c.ResponseWriter.Header().Set("Referrer-Policy", options.ReferrerPolicy)
+		}
+		if v := permissionsPolicyHeader(options.PermissionsPolicy); v != "" {
+			c.ResponseWriter.Header().Set("Permissions-Policy", v)
+		}
+		if options.CrossOriginOpenerPolicy != "" {
+			c.ResponseWriter.Header().Set("Cross-Origin-Opener-Policy", options.CrossOriginOpenerPolicy)
+		}
+		if options.CrossOriginEmbedderPolicy != "" {
+			c.ResponseWriter.Header().Set("Cross-Origin-Embedder-Policy", options.CrossOriginEmbedderPolicy)
+		}
+		if options.CrossOriginResourcePolicy != "" {
+			c.ResponseWriter.Header().Set("Cross-Origin-Resource-Policy", options.CrossOriginResourcePolicy)
 		}
 
-		// Set some "good practice" default headers.
-		c.ResponseWriter.Header().Set("X-Content-Type-Options", "nosniff")
-		c.ResponseWriter.Header().Set("X-XSS-Protection", "1; mode=block")
-
-		c.Next()
-	})
-}
-
-func hpkpHeader(o *Options) (v string, err error) {
-	if len(o.HPKP.Keys) == 0 {
-		err = errors.New("secure: at least one key must be set when using HPKP")
-		return
-	}
-
-	if o.HPKP.MaxAge == 0 {
-		err = errors.New("secure: max age must be set when using HPKP")
-		return
-	}
-
-	for _, key := range o.HPKP.Keys {
-		if v != "" {
-			v += "; "
+		// Set Content Security Policy headers.
+		if options.CSP != nil {
+			if v := cspHeader(options.CSP); v != "" {
+				if options.CSP.ReportOnly {
+					c.ResponseWriter.Header().Set("Content-Security-Policy-Report-Only", v)
+				} else {
+					c.ResponseWriter.Header().Set("Content-Security-Policy", v)
+					c.ResponseWriter.Header().Set("X-Content-Security-Policy", v)
+					c.ResponseWriter.Header().Set("X-WebKit-CSP", v)
+				}
+			}
 		}
-		v += fmt.Sprintf("pin-sha256=%q", key)
 	}
 
-	v += fmt.Sprintf("; %.f", o.HPKP.MaxAge.Seconds())
-
-	if o.HPKP.IncludeSubdomains {
-		v += "; includeSubdomains"
+	// If not explicitly allowed, displaying content inside a frame of a different origin is forbidden.
+	if options == nil || !options.FrameAllowed {
+		c.ResponseWriter.Header().Set("X-Frame-Options", "SAMEORIGIN")
 	}
 
-	if o.HPKP.ReportURI != "" {
-		v += fmt.Sprintf("; report-uri=%q", o.HPKP.ReportURI)
-	}
+	// Set some "good practice" default headers.
+	c.ResponseWriter.Header().Set("X-Content-Type-Options", "nosniff")
+	c.ResponseWriter.Header().Set("X-XSS-Protection", "1; mode=block")
 
-	return
+	return false
 }
 
-func hstsHeader(o *Options) (v string, err error) {
-	if !o.SSLForced {
-		err = errors.New("secure: SSLForced must be true when using HSTS")
-		return
-	}
-
-	if o.HSTS.MaxAge == 0 {
-		err = errors.New("secure: max age must be set when using HSTS")
-		return
-	}
-
-	if o.HSTS.Preload {
-		if o.HSTS.MaxAge < HSTSPreloadMinAge {
-			err = errors.New("secure: max age must be at least eighteen weeks when using HSTS preload")
-			return
-		}
-		if !o.HSTS.IncludeSubdomains {
-			err = errors.New("secure: subdomains must be included when using HSTS preload")
-			return
-		}
-	}
-
-	v += fmt.Sprintf("; %.f", o.HSTS.MaxAge.Seconds())
-
-	if o.HSTS.IncludeSubdomains {
-		v += "; includeSubdomains"
-	}
+// hpkpHeader builds the value of the Public-Key-Pins header from the HPKP options embedded in o.
+func hpkpHeader(o *Options) (string, error) {
+	return HPKPHeaderValue(o.HPKP)
+}
 
-	if o.HSTS.Preload {
-		v += "; preload"
+// hstsHeader builds the value of the Strict-Transport-Security header from the HSTS options embedded in o.
+// SSLForced is checked here, rather than in HSTSHeaderValue, because it's a property of the surrounding
+// Options, not of HSTS itself.
+func hstsHeader(o *Options) (string, error) {
+	if !o.SSLForced {
+		return "", errors.New("secure: SSLForced must be true when using HSTS")
 	}
-
-	return
+	return HSTSHeaderValue(o.HSTS)
 }
@@ -0,0 +1,37 @@
+package secure
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/volatile/core"
+)
+
+// TestApplyOmitsEmptyCSP checks that an Options.CSP that's non-nil but builds to an empty header value
+// doesn't get sent, since that's indistinguishable from "not configured" for every consumer of the header.
+func TestApplyOmitsEmptyCSP(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+
+	apply(&core.Context{Request: req, ResponseWriter: rec}, &Options{CSP: &CSPOptions{}})
+
+	for _, h := range []string{"Content-Security-Policy", "X-Content-Security-Policy", "X-WebKit-CSP"} {
+		if _, ok := rec.Header()[h]; ok {
+			t.Errorf("apply() set %s, want the header omitted entirely", h)
+		}
+	}
+}
+
+// TestApplyOmitsEmptyPermissionsPolicy checks that an Options.PermissionsPolicy that's non-nil but has no
+// directives doesn't get sent, since that's indistinguishable from "not configured" for every consumer of
+// the header.
+func TestApplyOmitsEmptyPermissionsPolicy(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+
+	apply(&core.Context{Request: req, ResponseWriter: rec}, &Options{PermissionsPolicy: map[string][]string{}})
+
+	if _, ok := rec.Header()["Permissions-Policy"]; ok {
+		t.Error("apply() set Permissions-Policy, want the header omitted entirely")
+	}
+}
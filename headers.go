@@ -0,0 +1,109 @@
+package secure
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// referrerPolicyTokens lists the valid Referrer-Policy tokens.
+// See https://www.w3.org/TR/referrer-policy/#referrer-policies.
+var referrerPolicyTokens = map[string]bool{
+	"no-referrer":                     true,
+	"no-referrer-when-downgrade":      true,
+	"origin":                          true,
+	"origin-when-cross-origin":        true,
+	"same-origin":                     true,
+	"strict-origin":                   true,
+	"strict-origin-when-cross-origin": true,
+	"unsafe-url":                      true,
+}
+
+// crossOriginOpenerPolicyTokens lists the valid Cross-Origin-Opener-Policy tokens.
+// See https://html.spec.whatwg.org/multipage/browsers.html#cross-origin-opener-policies.
+var crossOriginOpenerPolicyTokens = map[string]bool{
+	"unsafe-none":              true,
+	"same-origin-allow-popups": true,
+	"same-origin":              true,
+}
+
+// crossOriginEmbedderPolicyTokens lists the valid Cross-Origin-Embedder-Policy tokens.
+// See https://html.spec.whatwg.org/multipage/browsers.html#cross-origin-embedder-policies.
+var crossOriginEmbedderPolicyTokens = map[string]bool{
+	"unsafe-none":    true,
+	"require-corp":   true,
+	"credentialless": true,
+}
+
+// crossOriginResourcePolicyTokens lists the valid Cross-Origin-Resource-Policy tokens.
+// See https://fetch.spec.whatwg.org/#cross-origin-resource-policy-header.
+var crossOriginResourcePolicyTokens = map[string]bool{
+	"same-site":    true,
+	"same-origin":  true,
+	"cross-origin": true,
+}
+
+// permissionsPolicyKeywords lists the Permissions-Policy allowlist tokens that aren't quoted origins.
+// See https://www.w3.org/TR/permissions-policy-1/#structured-header.
+var permissionsPolicyKeywords = map[string]bool{
+	"self": true,
+	"*":    true,
+}
+
+// validateHeaderOptions checks that ReferrerPolicy, CrossOriginOpenerPolicy, CrossOriginEmbedderPolicy and
+// CrossOriginResourcePolicy hold either an empty string (the header is omitted) or one of their spec's
+// valid tokens, and that PermissionsPolicy's directive names are non-empty and its allowlist entries are
+// each either a known keyword or a double-quoted origin.
+func validateHeaderOptions(o *Options) error {
+	if o.ReferrerPolicy != "" && !referrerPolicyTokens[o.ReferrerPolicy] {
+		return fmt.Errorf("secure: %q is not a valid Referrer-Policy token", o.ReferrerPolicy)
+	}
+
+	if o.CrossOriginOpenerPolicy != "" && !crossOriginOpenerPolicyTokens[o.CrossOriginOpenerPolicy] {
+		return fmt.Errorf("secure: %q is not a valid Cross-Origin-Opener-Policy token", o.CrossOriginOpenerPolicy)
+	}
+
+	if o.CrossOriginEmbedderPolicy != "" && !crossOriginEmbedderPolicyTokens[o.CrossOriginEmbedderPolicy] {
+		return fmt.Errorf("secure: %q is not a valid Cross-Origin-Embedder-Policy token", o.CrossOriginEmbedderPolicy)
+	}
+
+	if o.CrossOriginResourcePolicy != "" && !crossOriginResourcePolicyTokens[o.CrossOriginResourcePolicy] {
+		return fmt.Errorf("secure: %q is not a valid Cross-Origin-Resource-Policy token", o.CrossOriginResourcePolicy)
+	}
+
+	for name, allowlist := range o.PermissionsPolicy {
+		if name == "" {
+			return errors.New("secure: Permissions-Policy directive name must not be empty")
+		}
+
+		for _, entry := range allowlist {
+			if permissionsPolicyKeywords[entry] {
+				continue
+			}
+			if len(entry) < 2 || entry[0] != '"' || entry[len(entry)-1] != '"' {
+				return fmt.Errorf("secure: Permissions-Policy directive %q has an invalid allowlist entry %q: want \"self\", \"*\" or a double-quoted origin", name, entry)
+			}
+		}
+	}
+
+	return nil
+}
+
+// permissionsPolicyHeader builds the value of the Permissions-Policy header from a directive name to
+// allowlist mapping, e.g. {"geolocation": {}, "camera": {"self", `"https://x"`}} becomes
+// `camera=(self "https://x"), geolocation=()`. Directives are sorted by name so the header is deterministic.
+func permissionsPolicyHeader(p map[string][]string) string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	directives := make([]string, 0, len(names))
+	for _, name := range names {
+		directives = append(directives, fmt.Sprintf("%s=(%s)", name, strings.Join(p[name], " ")))
+	}
+
+	return strings.Join(directives, ", ")
+}
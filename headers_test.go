@@ -0,0 +1,61 @@
+package secure
+
+import "testing"
+
+func TestValidateHeaderOptions(t *testing.T) {
+	valid := &Options{
+		ReferrerPolicy:            "strict-origin-when-cross-origin",
+		CrossOriginOpenerPolicy:   "same-origin",
+		CrossOriginEmbedderPolicy: "require-corp",
+		CrossOriginResourcePolicy: "same-site",
+	}
+	if err := validateHeaderOptions(valid); err != nil {
+		t.Errorf("validateHeaderOptions(valid) = %v, want nil", err)
+	}
+
+	if err := validateHeaderOptions(&Options{}); err != nil {
+		t.Errorf("validateHeaderOptions(empty) = %v, want nil", err)
+	}
+
+	invalid := []*Options{
+		{ReferrerPolicy: "not-a-token"},
+		{CrossOriginOpenerPolicy: "not-a-token"},
+		{CrossOriginEmbedderPolicy: "not-a-token"},
+		{CrossOriginResourcePolicy: "not-a-token"},
+	}
+	for _, o := range invalid {
+		if err := validateHeaderOptions(o); err == nil {
+			t.Errorf("validateHeaderOptions(%+v) = nil, want an error", o)
+		}
+	}
+}
+
+func TestValidateHeaderOptionsPermissionsPolicy(t *testing.T) {
+	valid := &Options{PermissionsPolicy: map[string][]string{
+		"camera":      {"self", `"https://example.com"`},
+		"geolocation": {"*"},
+		"microphone":  {},
+	}}
+	if err := validateHeaderOptions(valid); err != nil {
+		t.Errorf("validateHeaderOptions(valid PermissionsPolicy) = %v, want nil", err)
+	}
+
+	if err := validateHeaderOptions(&Options{PermissionsPolicy: map[string][]string{"": {"self"}}}); err == nil {
+		t.Error("expected an error for an empty directive name")
+	}
+
+	if err := validateHeaderOptions(&Options{PermissionsPolicy: map[string][]string{"camera": {"https://example.com"}}}); err == nil {
+		t.Error("expected an error for an unquoted origin in the allowlist")
+	}
+}
+
+func TestPermissionsPolicyHeader(t *testing.T) {
+	p := map[string][]string{
+		"camera":      {"self", `"https://example.com"`},
+		"geolocation": {},
+	}
+
+	if got, want := permissionsPolicyHeader(p), `camera=(self "https://example.com"), geolocation=()`; got != want {
+		t.Errorf("permissionsPolicyHeader() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,95 @@
+package secure
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HPKPDefaultMaxAge provides a default HPKP Max-Age value of 30 days.
+const HPKPDefaultMaxAge = 30 * 24 * time.Hour
+
+// HPKPOptions represents HTTP Public Key Pinning options.
+// See RFC 7469 and https://developer.mozilla.org/en-US/docs/Web/Security/Public_Key_Pinning.
+//
+// Deprecated: no current browser honors the Public-Key-Pins header, and Chrome removed support in 2018.
+// Set Deprecated to true to silence this package's warning once you've acknowledged it, but prefer
+// migrating to ExpectCTOptions and PinVerifier, which pin server-side instead of relying on the browser.
+type HPKPOptions struct {
+	Keys              []string      // Keys contains the Base64 encoded Subject Public Key Information (SPKI) fingerprints. This field is required.
+	MaxAge            time.Duration // MaxAge indicates how long the browser should remember that this site is only to be accessed using one of the pinned keys. This field is required.
+	IncludeSubdomains bool          // IncludeSubdomains indicates whether HPKP applies to all of the site's subdomains as well.
+	ReportURI         string        // ReportURI is the URL at which validation failures are reported to.
+	Deprecated        bool          // Deprecated silences the startup warning logged when HPKP is used. Acknowledging it doesn't make browsers support the header again.
+}
+
+// HPKPHeaderValue builds the value of the Public-Key-Pins header, e.g.
+// `pin-sha256="base64=="; max-age=2592000; includeSubDomains`. It can be used to apply HPKP outside of the
+// core middleware stack, such as in a plain net/http handler or a reverse proxy.
+func HPKPHeaderValue(o *HPKPOptions) (string, error) {
+	if len(o.Keys) == 0 {
+		return "", errors.New("secure: at least one key must be set when using HPKP")
+	}
+
+	if o.MaxAge == 0 {
+		return "", errors.New("secure: max age must be set when using HPKP")
+	}
+
+	var v string
+
+	for _, key := range o.Keys {
+		if v != "" {
+			v += "; "
+		}
+		v += fmt.Sprintf("pin-sha256=%q", key)
+	}
+
+	v += fmt.Sprintf("; max-age=%.f", o.MaxAge.Seconds())
+
+	if o.IncludeSubdomains {
+		v += "; includeSubDomains"
+	}
+
+	if o.ReportURI != "" {
+		v += fmt.Sprintf("; report-uri=%q", o.ReportURI)
+	}
+
+	return v, nil
+}
+
+// parseHPKPHeaderValue parses a Public-Key-Pins header value produced by HPKPHeaderValue back into an
+// HPKPOptions. It's intentionally narrow: it only has to understand what HPKPHeaderValue emits.
+func parseHPKPHeaderValue(v string) (*HPKPOptions, error) {
+	o := &HPKPOptions{}
+
+	for _, directive := range strings.Split(v, "; ") {
+		switch {
+		case strings.HasPrefix(directive, "pin-sha256="):
+			key, err := strconv.Unquote(strings.TrimPrefix(directive, "pin-sha256="))
+			if err != nil {
+				return nil, fmt.Errorf("secure: invalid pin-sha256 directive %q: %w", directive, err)
+			}
+			o.Keys = append(o.Keys, key)
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.ParseFloat(strings.TrimPrefix(directive, "max-age="), 64)
+			if err != nil {
+				return nil, fmt.Errorf("secure: invalid max-age directive %q: %w", directive, err)
+			}
+			o.MaxAge = time.Duration(seconds * float64(time.Second))
+		case directive == "includeSubDomains":
+			o.IncludeSubdomains = true
+		case strings.HasPrefix(directive, "report-uri="):
+			uri, err := strconv.Unquote(strings.TrimPrefix(directive, "report-uri="))
+			if err != nil {
+				return nil, fmt.Errorf("secure: invalid report-uri directive %q: %w", directive, err)
+			}
+			o.ReportURI = uri
+		default:
+			return nil, fmt.Errorf("secure: unknown Public-Key-Pins directive %q", directive)
+		}
+	}
+
+	return o, nil
+}
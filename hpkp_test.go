@@ -0,0 +1,89 @@
+package secure
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+// base64Alphabet mirrors the character set of a real Base64 encoded SPKI fingerprint. Keys built from it
+// never contain the "; " or '"' bytes our naive header parser splits and unquotes on.
+const base64Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/="
+
+func randBase64String(r *rand.Rand) string {
+	b := make([]byte, 1+r.Intn(43))
+	for i := range b {
+		b[i] = base64Alphabet[r.Intn(len(base64Alphabet))]
+	}
+	return string(b)
+}
+
+func TestHPKPHeaderValue(t *testing.T) {
+	v, err := HPKPHeaderValue(&HPKPOptions{
+		Keys:              []string{"base64=="},
+		MaxAge:            2592000 * time.Second,
+		IncludeSubdomains: true,
+		ReportURI:         "https://example.com/hpkp-report",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `pin-sha256="base64=="; max-age=2592000; includeSubDomains; report-uri="https://example.com/hpkp-report"`; v != want {
+		t.Errorf("HPKPHeaderValue() = %q, want %q", v, want)
+	}
+
+	if strings.HasPrefix(v, ";") || strings.Contains(v, "; ;") {
+		t.Errorf("HPKPHeaderValue() = %q, has a stray leading separator", v)
+	}
+}
+
+func TestHPKPHeaderValueErrors(t *testing.T) {
+	if _, err := HPKPHeaderValue(&HPKPOptions{MaxAge: time.Second}); err == nil {
+		t.Error("expected an error when Keys is empty")
+	}
+
+	if _, err := HPKPHeaderValue(&HPKPOptions{Keys: []string{"base64=="}}); err == nil {
+		t.Error("expected an error when MaxAge is zero")
+	}
+}
+
+// TestHPKPHeaderValueRoundTrip fuzzes Keys and MaxAge and checks that parsing back the header produced by
+// HPKPHeaderValue always yields the original options.
+func TestHPKPHeaderValueRoundTrip(t *testing.T) {
+	f := func(key1, key2 string, seconds uint32, includeSubdomains bool) bool {
+		o := &HPKPOptions{
+			Keys:              []string{key1, key2},
+			MaxAge:            time.Duration(seconds)*time.Second + time.Second,
+			IncludeSubdomains: includeSubdomains,
+		}
+
+		v, err := HPKPHeaderValue(o)
+		if err != nil {
+			t.Fatalf("HPKPHeaderValue(%+v): %v", o, err)
+		}
+
+		got, err := parseHPKPHeaderValue(v)
+		if err != nil {
+			t.Fatalf("parseHPKPHeaderValue(%q): %v", v, err)
+		}
+
+		return got.Keys[0] == o.Keys[0] && got.Keys[1] == o.Keys[1] && got.MaxAge == o.MaxAge && got.IncludeSubdomains == o.IncludeSubdomains
+	}
+
+	config := &quick.Config{
+		Values: func(args []reflect.Value, r *rand.Rand) {
+			args[0] = reflect.ValueOf(randBase64String(r))
+			args[1] = reflect.ValueOf(randBase64String(r))
+			args[2] = reflect.ValueOf(uint32(r.Intn(1 << 30)))
+			args[3] = reflect.ValueOf(r.Intn(2) == 0)
+		},
+	}
+
+	if err := quick.Check(f, config); err != nil {
+		t.Error(err)
+	}
+}
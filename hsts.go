@@ -0,0 +1,81 @@
+package secure
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// HSTSDefaultMaxAge provides a default HSTS Max-Age value of 30 days.
+	HSTSDefaultMaxAge = 30 * 24 * time.Hour
+	// HSTSPreloadMinAge is the lowest max age, in seconds, usable with HSTS preload: one year, as required
+	// by https://hstspreload.org. Submission used to accept eighteen weeks, but that minimum was tightened
+	// years ago.
+	HSTSPreloadMinAge = 365 * 24 * 60 * 60
+)
+
+// HSTSOptions represents HTTP Strict Transport Security options.
+// See RFC 6797 and https://developer.mozilla.org/en-US/docs/Web/Security/HTTP_strict_transport_security.
+type HSTSOptions struct {
+	MaxAge            time.Duration // MaxAge indicates how long the browser should remember that this site is only to be accessed using HTTPS. This field is required.
+	IncludeSubdomains bool          // IncludeSubdomains indicates whether HSTS applies to all of the site's subdomains as well.
+	Preload           bool          // Preload indicates whether the browsers must use a secure connection. It's not a standard. See https://hstspreload.appspot.com.
+}
+
+// HSTSHeaderValue builds the value of the Strict-Transport-Security header, e.g.
+// `max-age=2592000; includeSubDomains; preload`. It can be used to apply HSTS outside of the core
+// middleware stack, such as in a plain net/http handler or a reverse proxy.
+func HSTSHeaderValue(o *HSTSOptions) (string, error) {
+	if o.MaxAge == 0 {
+		return "", errors.New("secure: max age must be set when using HSTS")
+	}
+
+	if o.Preload {
+		if o.MaxAge < HSTSPreloadMinAge*time.Second {
+			return "", errors.New("secure: max age must be at least one year when using HSTS preload")
+		}
+		if !o.IncludeSubdomains {
+			return "", errors.New("secure: subdomains must be included when using HSTS preload")
+		}
+	}
+
+	v := fmt.Sprintf("max-age=%.f", o.MaxAge.Seconds())
+
+	if o.IncludeSubdomains {
+		v += "; includeSubDomains"
+	}
+
+	if o.Preload {
+		v += "; preload"
+	}
+
+	return v, nil
+}
+
+// parseHSTSHeaderValue parses a Strict-Transport-Security header value produced by HSTSHeaderValue back
+// into an HSTSOptions. It's intentionally narrow: it only has to understand what HSTSHeaderValue emits.
+func parseHSTSHeaderValue(v string) (*HSTSOptions, error) {
+	o := &HSTSOptions{}
+
+	for _, directive := range strings.Split(v, "; ") {
+		switch {
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.ParseFloat(strings.TrimPrefix(directive, "max-age="), 64)
+			if err != nil {
+				return nil, fmt.Errorf("secure: invalid max-age directive %q: %w", directive, err)
+			}
+			o.MaxAge = time.Duration(seconds * float64(time.Second))
+		case directive == "includeSubDomains":
+			o.IncludeSubdomains = true
+		case directive == "preload":
+			o.Preload = true
+		default:
+			return nil, fmt.Errorf("secure: unknown Strict-Transport-Security directive %q", directive)
+		}
+	}
+
+	return o, nil
+}
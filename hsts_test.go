@@ -0,0 +1,61 @@
+package secure
+
+import (
+	"strings"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+func TestHSTSHeaderValue(t *testing.T) {
+	v, err := HSTSHeaderValue(&HSTSOptions{MaxAge: HSTSPreloadMinAge * time.Second, IncludeSubdomains: true, Preload: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "max-age=31536000; includeSubDomains; preload"; v != want {
+		t.Errorf("HSTSHeaderValue() = %q, want %q", v, want)
+	}
+
+	if strings.HasPrefix(v, ";") || strings.Contains(v, "; ;") {
+		t.Errorf("HSTSHeaderValue() = %q, has a stray leading separator", v)
+	}
+}
+
+func TestHSTSHeaderValueErrors(t *testing.T) {
+	if _, err := HSTSHeaderValue(&HSTSOptions{}); err == nil {
+		t.Error("expected an error when MaxAge is zero")
+	}
+
+	if _, err := HSTSHeaderValue(&HSTSOptions{MaxAge: time.Second, Preload: true}); err == nil {
+		t.Error("expected an error when Preload is set below HSTSPreloadMinAge")
+	}
+
+	if _, err := HSTSHeaderValue(&HSTSOptions{MaxAge: HSTSPreloadMinAge * time.Second, Preload: true}); err == nil {
+		t.Error("expected an error when Preload is set without IncludeSubdomains")
+	}
+}
+
+// TestHSTSHeaderValueRoundTrip fuzzes MaxAge and IncludeSubdomains and checks that parsing back the
+// header produced by HSTSHeaderValue always yields the original options.
+func TestHSTSHeaderValueRoundTrip(t *testing.T) {
+	f := func(seconds uint32, includeSubdomains bool) bool {
+		o := &HSTSOptions{MaxAge: time.Duration(seconds)*time.Second + time.Second, IncludeSubdomains: includeSubdomains}
+
+		v, err := HSTSHeaderValue(o)
+		if err != nil {
+			t.Fatalf("HSTSHeaderValue(%+v): %v", o, err)
+		}
+
+		got, err := parseHSTSHeaderValue(v)
+		if err != nil {
+			t.Fatalf("parseHSTSHeaderValue(%q): %v", v, err)
+		}
+
+		return *got == *o
+	}
+
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
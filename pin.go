@@ -0,0 +1,94 @@
+package secure
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+)
+
+// PinVerifier checks that a certificate's Subject Public Key Information matches a configured pin.
+// Unlike HPKP, which asked browsers to remember and enforce pins on subsequent visits, PinVerifier
+// enforces pinning without relying on the browser, so it has no trust-on-first-use window and no risk of
+// locking out legitimate visitors after it's removed.
+//
+// VerifyConnection checks the chain presented by the *other* party to a handshake, which makes it a fit
+// for pinning a server this program dials out to, not for a plain http.Server (with no client certificate
+// requested, tls.ConnectionState.PeerCertificates is always empty on the server side, so VerifyConnection
+// would reject every connection):
+//
+//	v := &secure.PinVerifier{Pins: []string{"base64-spki-sha256=="}}
+//	transport := &http.Transport{TLSClientConfig: &tls.Config{VerifyConnection: v.VerifyConnection}}
+//	client := &http.Client{Transport: transport}
+//
+// To pin the certificate an http.Server itself presents, wrap its GetCertificate with WrapGetCertificate
+// instead.
+type PinVerifier struct {
+	Pins          []string                          // Pins contains the Base64 encoded SHA-256 SPKI fingerprints that are accepted. This field is required.
+	BackupPins    []string                          // BackupPins contains additional Base64 encoded SHA-256 SPKI fingerprints, accepted the same way as Pins, kept in reserve for key rotation.
+	ReportFailure func(*tls.ConnectionState, error) // ReportFailure, when set, is called by VerifyConnection with the failed connection state whenever no certificate in the chain matches a pin.
+}
+
+// VerifyConnection matches the signature of tls.Config.VerifyConnection. It returns nil as soon as one
+// certificate in cs.PeerCertificates matches a pin, and a non-nil error otherwise.
+func (v *PinVerifier) VerifyConnection(cs tls.ConnectionState) error {
+	for _, cert := range cs.PeerCertificates {
+		if v.matches(cert) {
+			return nil
+		}
+	}
+
+	err := errors.New("secure: no certificate in the peer chain matches a configured pin")
+	if v.ReportFailure != nil {
+		v.ReportFailure(&cs, err)
+	}
+	return err
+}
+
+// WrapGetCertificate wraps a tls.Config.GetCertificate function, rejecting the certificate it would have
+// returned when its leaf doesn't match a configured pin, instead of letting a server present a
+// misconfigured or wrongly rotated certificate to its peers:
+//
+//	v := &secure.PinVerifier{Pins: []string{"base64-spki-sha256=="}}
+//	server.TLSConfig.GetCertificate = v.WrapGetCertificate(server.TLSConfig.GetCertificate)
+func (v *PinVerifier) WrapGetCertificate(getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert, err := getCertificate(hello)
+		if err != nil {
+			return nil, err
+		}
+
+		leaf := cert.Leaf
+		if leaf == nil {
+			leaf, err = x509.ParseCertificate(cert.Certificate[0])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if !v.matches(leaf) {
+			return nil, errors.New("secure: server certificate doesn't match a configured pin")
+		}
+
+		return cert, nil
+	}
+}
+
+// matches reports whether cert's SPKI fingerprint is one of v's configured pins.
+func (v *PinVerifier) matches(cert *x509.Certificate) bool {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	fp := base64.StdEncoding.EncodeToString(sum[:])
+
+	for _, p := range v.Pins {
+		if p == fp {
+			return true
+		}
+	}
+	for _, p := range v.BackupPins {
+		if p == fp {
+			return true
+		}
+	}
+	return false
+}
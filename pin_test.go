@@ -0,0 +1,92 @@
+package secure
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// genTestCert returns a self-signed certificate along with its Base64 encoded SHA-256 SPKI fingerprint.
+func genTestCert(t *testing.T) (tls.Certificate, string) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv}, base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestPinVerifierVerifyConnection(t *testing.T) {
+	cert, pin := genTestCert(t)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs := tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}}
+
+	if err := (&PinVerifier{Pins: []string{pin}}).VerifyConnection(cs); err != nil {
+		t.Errorf("VerifyConnection() with a matching pin = %v, want nil", err)
+	}
+
+	if err := (&PinVerifier{BackupPins: []string{pin}}).VerifyConnection(cs); err != nil {
+		t.Errorf("VerifyConnection() with a matching backup pin = %v, want nil", err)
+	}
+
+	var reported error
+	v := &PinVerifier{
+		Pins:          []string{"not-the-right-pin"},
+		ReportFailure: func(_ *tls.ConnectionState, err error) { reported = err },
+	}
+	if err := v.VerifyConnection(cs); err == nil {
+		t.Error("VerifyConnection() with no matching pin = nil, want an error")
+	}
+	if reported == nil {
+		t.Error("VerifyConnection() with no matching pin didn't call ReportFailure")
+	}
+}
+
+func TestPinVerifierWrapGetCertificate(t *testing.T) {
+	cert, pin := genTestCert(t)
+	getCertificate := func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return &cert, nil }
+
+	v := &PinVerifier{Pins: []string{pin}}
+	got, err := v.WrapGetCertificate(getCertificate)(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatalf("WrapGetCertificate() with a matching pin = %v", err)
+	}
+	if got != &cert {
+		t.Error("WrapGetCertificate() with a matching pin returned a different certificate")
+	}
+
+	mismatched := &PinVerifier{Pins: []string{"not-the-right-pin"}}
+	if _, err := mismatched.WrapGetCertificate(getCertificate)(&tls.ClientHelloInfo{}); err == nil {
+		t.Error("WrapGetCertificate() with no matching pin = nil error, want one")
+	}
+}
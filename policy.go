@@ -0,0 +1,263 @@
+package secure
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/volatile/core"
+)
+
+// Policy represents one set of security Options scoped to an optional host and path prefix. Build one
+// with NewPolicy and its fluent With* and For* methods, then register it on a PolicySet with Add. A
+// Policy left unscoped by ForHost/ForPath matches every request.
+type Policy struct {
+	options *Options
+	host    string
+	path    string
+}
+
+// NewPolicy starts building a Policy.
+func NewPolicy() *Policy {
+	return &Policy{options: &Options{}}
+}
+
+// ForHost scopes the policy to requests whose Host matches host exactly, or, if host starts with "*.", to
+// any subdomain of the suffix that follows it.
+func (p *Policy) ForHost(host string) *Policy {
+	p.host = host
+	return p
+}
+
+// ForPath scopes the policy to requests whose URL path starts with path, compared whole segment by whole
+// segment: "/admin" matches "/admin" and "/admin/users", but not "/administrator".
+func (p *Policy) ForPath(path string) *Policy {
+	p.path = path
+	return p
+}
+
+// WithAllowedHosts sets Options.AllowedHosts.
+func (p *Policy) WithAllowedHosts(hosts ...string) *Policy {
+	p.options.AllowedHosts = hosts
+	return p
+}
+
+// WithCSP sets Options.CSP.
+func (p *Policy) WithCSP(o *CSPOptions) *Policy {
+	p.options.CSP = o
+	return p
+}
+
+// WithCrossOriginEmbedderPolicy sets Options.CrossOriginEmbedderPolicy.
+func (p *Policy) WithCrossOriginEmbedderPolicy(policy string) *Policy {
+	p.options.CrossOriginEmbedderPolicy = policy
+	return p
+}
+
+// WithCrossOriginOpenerPolicy sets Options.CrossOriginOpenerPolicy.
+func (p *Policy) WithCrossOriginOpenerPolicy(policy string) *Policy {
+	p.options.CrossOriginOpenerPolicy = policy
+	return p
+}
+
+// WithCrossOriginResourcePolicy sets Options.CrossOriginResourcePolicy.
+func (p *Policy) WithCrossOriginResourcePolicy(policy string) *Policy {
+	p.options.CrossOriginResourcePolicy = policy
+	return p
+}
+
+// WithExpectCT sets Options.ExpectCT.
+func (p *Policy) WithExpectCT(o *ExpectCTOptions) *Policy {
+	p.options.ExpectCT = o
+	return p
+}
+
+// WithFrameAllowed sets Options.FrameAllowed.
+func (p *Policy) WithFrameAllowed(allowed bool) *Policy {
+	p.options.FrameAllowed = allowed
+	return p
+}
+
+// WithHPKP sets Options.HPKP.
+func (p *Policy) WithHPKP(o *HPKPOptions) *Policy {
+	p.options.HPKP = o
+	return p
+}
+
+// WithHSTS sets Options.HSTS.
+func (p *Policy) WithHSTS(o *HSTSOptions) *Policy {
+	p.options.HSTS = o
+	return p
+}
+
+// WithPermissionsPolicy sets Options.PermissionsPolicy.
+func (p *Policy) WithPermissionsPolicy(directives map[string][]string) *Policy {
+	p.options.PermissionsPolicy = directives
+	return p
+}
+
+// WithReferrerPolicy sets Options.ReferrerPolicy.
+func (p *Policy) WithReferrerPolicy(policy string) *Policy {
+	p.options.ReferrerPolicy = policy
+	return p
+}
+
+// WithSSLForced sets Options.SSLForced.
+func (p *Policy) WithSSLForced(forced bool) *Policy {
+	p.options.SSLForced = forced
+	return p
+}
+
+// pathNode is one node of a PolicySet's compiled per-host path trie. Matching a request path costs one
+// map lookup per path segment, rather than a scan of every registered Policy.
+type pathNode struct {
+	options  *Options
+	hasMatch bool
+	children map[string]*pathNode
+}
+
+// match walks node following segments, returning the Options of the deepest node reached that was
+// registered as a Policy, i.e. the longest matching path prefix.
+func (node *pathNode) match(segments []string) *Options {
+	var best *pathNode
+	if node.hasMatch {
+		best = node
+	}
+
+	for _, seg := range segments {
+		child, ok := node.children[seg]
+		if !ok {
+			break
+		}
+		node = child
+		if node.hasMatch {
+			best = node
+		}
+	}
+
+	if best == nil {
+		return nil
+	}
+	return best.options
+}
+
+// PolicySet matches an incoming request against a set of per-host, per-path Policy overrides and applies
+// the most specific match: an exact host beats a wildcard host beats no host restriction at all, and
+// within a host the longest matching path prefix wins.
+type PolicySet struct {
+	mu       sync.Mutex
+	policies []*Policy
+	hosts    map[string]*pathNode
+	built    bool
+}
+
+// NewPolicySet creates an empty PolicySet.
+func NewPolicySet() *PolicySet {
+	return &PolicySet{}
+}
+
+// Add registers a Policy on the set. Its Options are validated immediately, the same way Use validates
+// the Options passed to it.
+func (ps *PolicySet) Add(p *Policy) *PolicySet {
+	validateOptions(p.options)
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.policies = append(ps.policies, p)
+	ps.built = false
+	return ps
+}
+
+// build compiles the registered policies into a per-host path trie. Callers must hold ps.mu.
+func (ps *PolicySet) build() {
+	ps.hosts = map[string]*pathNode{}
+
+	for _, p := range ps.policies {
+		root, ok := ps.hosts[p.host]
+		if !ok {
+			root = &pathNode{}
+			ps.hosts[p.host] = root
+		}
+
+		node := root
+		if trimmed := strings.Trim(p.path, "/"); trimmed != "" {
+			for _, seg := range strings.Split(trimmed, "/") {
+				if node.children == nil {
+					node.children = map[string]*pathNode{}
+				}
+				child, ok := node.children[seg]
+				if !ok {
+					child = &pathNode{}
+					node.children[seg] = child
+				}
+				node = child
+			}
+		}
+
+		node.options = p.options
+		node.hasMatch = true
+	}
+
+	ps.built = true
+}
+
+// optionsFor returns the Options of the most specific Policy matching host and path, or nil if none do.
+func (ps *PolicySet) optionsFor(host, path string) *Options {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if !ps.built {
+		ps.build()
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	if root, ok := ps.hosts[host]; ok {
+		if o := root.match(segments); o != nil {
+			return o
+		}
+	}
+
+	// Try wildcard hosts registered as "*.example.com", from the most to the least specific subdomain.
+	for suffix := host; ; {
+		i := strings.IndexByte(suffix, '.')
+		if i < 0 {
+			break
+		}
+		suffix = suffix[i+1:]
+		if root, ok := ps.hosts["*."+suffix]; ok {
+			if o := root.match(segments); o != nil {
+				return o
+			}
+		}
+	}
+
+	if root, ok := ps.hosts[""]; ok {
+		return root.match(segments)
+	}
+
+	return nil
+}
+
+// UseWithPolicies adds the handler to the default handlers stack, applying the Options of the most
+// specific Policy registered in ps to each request. Requests that match no Policy get the same baseline
+// headers Use(nil) would set.
+func UseWithPolicies(ps *PolicySet) {
+	core.Use(func(c *core.Context) {
+		if apply(c, ps.optionsFor(requestHost(c.Request), c.Request.URL.Path)) {
+			return
+		}
+		c.Next()
+	})
+}
+
+// requestHost returns the host r was addressed to, stripped of any port. For a server-side request,
+// net/http leaves URL.Host empty and reports the Host header through Request.Host instead, so that's
+// what ForHost must be matched against.
+func requestHost(r *http.Request) string {
+	if h, _, err := net.SplitHostPort(r.Host); err == nil {
+		return h
+	}
+	return r.Host
+}
@@ -0,0 +1,76 @@
+package secure
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestPolicySetOptionsFor(t *testing.T) {
+	ps := NewPolicySet()
+	ps.Add(NewPolicy().WithReferrerPolicy("no-referrer"))
+	ps.Add(NewPolicy().ForHost("a.example.com").WithReferrerPolicy("same-origin"))
+	ps.Add(NewPolicy().ForHost("a.example.com").ForPath("/admin").WithReferrerPolicy("strict-origin"))
+	ps.Add(NewPolicy().ForHost("*.example.com").WithReferrerPolicy("no-referrer-when-downgrade"))
+
+	tests := []struct {
+		host, path string
+		want       string
+	}{
+		{"a.example.com", "/admin/users", "strict-origin"},   // most specific: host + path prefix
+		{"a.example.com", "/", "same-origin"},                // host match, no path override
+		{"b.example.com", "/", "no-referrer-when-downgrade"}, // wildcard host
+		{"other.example.org", "/", "no-referrer"},            // falls back to the unscoped policy
+	}
+
+	for _, tt := range tests {
+		o := ps.optionsFor(tt.host, tt.path)
+		if o == nil || o.ReferrerPolicy != tt.want {
+			t.Errorf("optionsFor(%q, %q) = %+v, want ReferrerPolicy %q", tt.host, tt.path, o, tt.want)
+		}
+	}
+}
+
+func TestPolicySetOptionsForUnmatchedHost(t *testing.T) {
+	ps := NewPolicySet()
+	ps.Add(NewPolicy().ForHost("a.example.com").WithReferrerPolicy("same-origin"))
+
+	if o := ps.optionsFor("unrelated.example.net", "/"); o != nil {
+		t.Errorf("optionsFor() on an unrelated host = %+v, want nil", o)
+	}
+}
+
+func TestRequestHost(t *testing.T) {
+	req := httptest.NewRequest("GET", "/admin", nil)
+	req.Host = "a.example.com:8443"
+
+	if got, want := requestHost(req), "a.example.com"; got != want {
+		t.Errorf("requestHost() = %q, want %q", got, want)
+	}
+
+	req.Host = "a.example.com"
+	if got, want := requestHost(req), "a.example.com"; got != want {
+		t.Errorf("requestHost() without a port = %q, want %q", got, want)
+	}
+}
+
+// TestPolicySetRace exercises Add and optionsFor concurrently, the realistic "add a policy while already
+// serving traffic" case the mutex is meant to support.
+func TestPolicySetRace(t *testing.T) {
+	ps := NewPolicySet()
+	ps.Add(NewPolicy().ForHost("a.example.com").WithReferrerPolicy("no-referrer"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			ps.Add(NewPolicy().ForHost("b.example.com").WithReferrerPolicy("no-referrer"))
+		}()
+		go func() {
+			defer wg.Done()
+			ps.optionsFor("a.example.com", "/")
+		}()
+	}
+	wg.Wait()
+}
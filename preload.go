@@ -0,0 +1,134 @@
+package secure
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/volatile/core"
+)
+
+// preloadCheckTimeout bounds each live connection PreloadCheckPath's handler makes while checking a host.
+const preloadCheckTimeout = 5 * time.Second
+
+// Issue represents one problem found while checking a configuration's readiness for the HSTS preload list
+// at https://hstspreload.org.
+type Issue struct {
+	Check   string `json:"check"`   // Check names the requirement that failed, e.g. "max-age".
+	Message string `json:"message"` // Message explains what's wrong and how to fix it.
+}
+
+// PreloadReadiness checks options against the static hstspreload.org submission requirements: SSLForced,
+// a max-age of at least HSTSPreloadMinAge, includeSubDomains and preload. It can't check the requirements
+// that need a live connection - the HTTP-to-HTTPS redirect, and the apex and www certificate chains - the
+// handler mounted at Options.PreloadCheckPath checks those as well.
+func PreloadReadiness(options *Options) []Issue {
+	if options == nil || options.HSTS == nil {
+		return []Issue{{"hsts", "HSTS must be configured"}}
+	}
+
+	var issues []Issue
+
+	if !options.SSLForced {
+		issues = append(issues, Issue{"ssl-forced", "SSLForced must be true: insecure requests must redirect to HTTPS"})
+	}
+
+	if options.HSTS.MaxAge < HSTSPreloadMinAge*time.Second {
+		issues = append(issues, Issue{"max-age", fmt.Sprintf("max-age must be at least %d seconds (one year), got %.f", HSTSPreloadMinAge, options.HSTS.MaxAge.Seconds())})
+	}
+
+	if !options.HSTS.IncludeSubdomains {
+		issues = append(issues, Issue{"include-subdomains", "includeSubDomains must be set"})
+	}
+
+	if !options.HSTS.Preload {
+		issues = append(issues, Issue{"preload", "preload must be set"})
+	}
+
+	return issues
+}
+
+// checkRedirect reports an Issue unless an HTTP request to host is redirected to HTTPS.
+func checkRedirect(host string) *Issue {
+	client := &http.Client{
+		Timeout:       preloadCheckTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	resp, err := client.Get("http://" + host + "/")
+	if err != nil {
+		return &Issue{"http-redirect", fmt.Sprintf("couldn't connect over HTTP to check the redirect: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 || !strings.HasPrefix(resp.Header.Get("Location"), "https://") {
+		return &Issue{"http-redirect", "http://" + host + "/ must redirect to https://"}
+	}
+
+	return nil
+}
+
+// checkCertificate reports an Issue unless host serves a valid certificate chain over HTTPS.
+func checkCertificate(host string) *Issue {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: preloadCheckTimeout}, "tcp", host+":443", nil)
+	if err != nil {
+		return &Issue{"certificate", fmt.Sprintf("couldn't establish a TLS connection to %s: %v", host, err)}
+	}
+	conn.Close()
+
+	return nil
+}
+
+// allowedPreloadHost returns host, stripped of any port, if it's configured in allowedHosts, or "" if
+// it isn't. The live checks dial out to whatever host is returned, so trusting the request's Host header
+// directly would let anyone point this server at an arbitrary host or IP (SSRF) and tie up the handler
+// for up to 3*preloadCheckTimeout per request; requiring an explicit AllowedHosts entry closes that off.
+func allowedPreloadHost(host string, allowedHosts []string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	for _, h := range allowedHosts {
+		if h == host {
+			return host
+		}
+	}
+
+	return ""
+}
+
+// preloadReport is the JSON body written by the Options.PreloadCheckPath handler.
+type preloadReport struct {
+	Ready  bool    `json:"ready"`
+	Issues []Issue `json:"issues"`
+}
+
+// writePreloadReport runs PreloadReadiness plus, when the request's Host is listed in AllowedHosts, the
+// live checks that need a connection - the HTTP redirect, and the apex and www certificate chains - and
+// writes the combined result as JSON, suitable for CI gating. For any other Host the live checks are skipped.
+func writePreloadReport(c *core.Context, options *Options) {
+	issues := PreloadReadiness(options)
+
+	if host := allowedPreloadHost(c.Request.Host, options.AllowedHosts); host != "" {
+		for _, issue := range []*Issue{checkRedirect(host), checkCertificate(host), checkCertificate("www." + host)} {
+			if issue != nil {
+				issues = append(issues, *issue)
+			}
+		}
+	}
+
+	report := preloadReport{Ready: len(issues) == 0, Issues: issues}
+	if report.Issues == nil {
+		report.Issues = []Issue{}
+	}
+
+	c.ResponseWriter.Header().Set("Content-Type", "application/json")
+	if !report.Ready {
+		c.ResponseWriter.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(c.ResponseWriter).Encode(report)
+}
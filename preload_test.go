@@ -0,0 +1,47 @@
+package secure
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPreloadReadiness(t *testing.T) {
+	ready := &Options{SSLForced: true, HSTS: &HSTSOptions{MaxAge: HSTSPreloadMinAge * time.Second, IncludeSubdomains: true, Preload: true}}
+	if issues := PreloadReadiness(ready); len(issues) != 0 {
+		t.Errorf("PreloadReadiness(ready) = %+v, want no issues", issues)
+	}
+
+	if issues := PreloadReadiness(nil); len(issues) != 1 {
+		t.Errorf("PreloadReadiness(nil) = %+v, want exactly one issue", issues)
+	}
+
+	if issues := PreloadReadiness(&Options{}); len(issues) != 1 {
+		t.Errorf("PreloadReadiness(no HSTS) = %+v, want exactly one issue", issues)
+	}
+
+	incomplete := &Options{HSTS: &HSTSOptions{MaxAge: 1}}
+	issues := PreloadReadiness(incomplete)
+	if len(issues) != 4 {
+		t.Fatalf("PreloadReadiness(incomplete) = %+v, want 4 issues (ssl-forced, max-age, include-subdomains, preload)", issues)
+	}
+}
+
+func TestAllowedPreloadHost(t *testing.T) {
+	allowed := []string{"example.com"}
+
+	if got, want := allowedPreloadHost("example.com", allowed), "example.com"; got != want {
+		t.Errorf("allowedPreloadHost() = %q, want %q", got, want)
+	}
+
+	if got, want := allowedPreloadHost("example.com:8443", allowed), "example.com"; got != want {
+		t.Errorf("allowedPreloadHost() with a port = %q, want %q", got, want)
+	}
+
+	if got := allowedPreloadHost("attacker.example", allowed); got != "" {
+		t.Errorf("allowedPreloadHost() for a host outside AllowedHosts = %q, want \"\"", got)
+	}
+
+	if got := allowedPreloadHost("attacker.example", nil); got != "" {
+		t.Errorf("allowedPreloadHost() with no AllowedHosts configured = %q, want \"\" (nothing to safely check against)", got)
+	}
+}